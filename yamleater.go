@@ -3,13 +3,22 @@ package yamleater
 import (
 	"bufio"
 	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
+	"unicode"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	apischeme "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
 	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes/scheme"
 )
@@ -27,11 +36,43 @@ var (
 	emptyJSON   = []byte(`{}`)
 )
 
+// ErrClosed is returned by Next/NextObj (and their variants) once Close has been called.
+var ErrClosed = errors.New(`yamleater: eater is closed`)
+
 type decodeResult struct {
 	Obj runtime.Object
 	Gvk *apischeme.GroupVersionKind
 }
 
+// rawDocument is what the producer goroutine pushes onto readChan: a document's raw
+// bytes plus where it came from in the source. seq is a monotonic index assigned in
+// send(), used by the parallel decode pipeline to restore original ordering.
+type rawDocument struct {
+	data []byte
+	loc  DocumentLocation
+	seq  uint64
+}
+
+// decodedDoc is what the parallel decode pipeline pushes onto decodedChan: a rawDocument
+// plus its decode result, still carrying seq so the resequencer can reorder it.
+type decodedDoc struct {
+	rawDocument
+	obj runtime.Object
+	gvk *apischeme.GroupVersionKind
+	err error
+}
+
+// DocumentLocation describes where a document came from in the original source, for
+// downstream linters/validators that want to report "file.yaml:42: field X invalid".
+type DocumentLocation struct {
+	// StartLine and EndLine are the 1-indexed line range the document occupied in the
+	// source. Only populated for YAML input; JSON input leaves these zero.
+	StartLine int
+	EndLine   int
+	// ByteOffset is the byte offset of the start of the document in the source.
+	ByteOffset int64
+}
+
 type yamlEater struct {
 	in         io.Reader
 	current    []byte
@@ -39,38 +80,161 @@ type yamlEater struct {
 
 	yamlReader *k8syaml.YAMLReader
 	errRead    error
-	readChan   chan []byte
+	readChan   chan rawDocument
+	readAhead  uint
+	readSeq    uint64
+	currentLoc DocumentLocation
+
+	yamlDecoder          runtime.Decoder
+	customDecoder        runtime.Decoder
+	scheme               *runtime.Scheme
+	errDecode            error
+	unstructuredFallback bool
+
+	parallelDecode int
+	decodedChan    chan decodedDoc
 
-	yamlDecoder runtime.Decoder
-	errDecode   error
+	ctx             context.Context
+	cancel          context.CancelFunc
+	closeOnce       sync.Once
+	closeReaderOnce sync.Once
+}
+
+// Option configures optional behavior of a yamlEater. Pass zero or more to NewYamlEater.
+type Option func(*yamlEater)
+
+// WithUnstructuredFallback makes NextObj/CurrentObj fall back to decoding into
+// *unstructured.Unstructured (or *unstructured.UnstructuredList for a "*List" kind)
+// whenever the universal deserializer reports the GVK as not registered, eg. CRDs
+// such as Argo Workflow, cert-manager Certificate or Istio VirtualService.
+func WithUnstructuredFallback() Option {
+	return func(e *yamlEater) {
+		e.unstructuredFallback = true
+	}
+}
+
+// WithScheme makes NextObj/CurrentObj decode through a codec factory built on s instead
+// of client-go's built-in scheme, so callers can register cert-manager, Argo, Istio,
+// Gateway API, etc. and get those back as typed objects. Ignored if WithDecoder is also given.
+func WithScheme(s *runtime.Scheme) Option {
+	return func(e *yamlEater) {
+		e.scheme = s
+	}
+}
+
+// WithDecoder overrides the decoder used by NextObj/CurrentObj entirely, for callers
+// with their own codec factory. Takes precedence over WithScheme.
+func WithDecoder(d runtime.Decoder) Option {
+	return func(e *yamlEater) {
+		e.customDecoder = d
+	}
+}
+
+// WithReadAhead overrides the package-level ReadAhead default for a single yamlEater,
+// controlling how many raw documents are buffered ahead of decoding.
+func WithReadAhead(n uint) Option {
+	return func(e *yamlEater) {
+		e.readAhead = n
+	}
+}
+
+// WithContext ties the eater's read-ahead goroutine to ctx, so it can be cancelled
+// alongside eg. an inbound HTTP request. See Close.
+func WithContext(ctx context.Context) Option {
+	return func(e *yamlEater) {
+		e.ctx = ctx
+	}
+}
+
+// WithParallelDecode runs NextObj's decode step on n worker goroutines instead of on the
+// caller's goroutine, which matters for bundles where decoding (YAML->JSON->typed object)
+// dominates CPU. Results are resequenced back into original document order before NextObj
+// returns them, so behavior is unchanged other than throughput. Only use NextObj, not Next,
+// to consume an eater configured this way: Next still reads raw documents directly off the
+// same channel the decode workers consume from, so mixing the two would split the stream
+// between them.
+func WithParallelDecode(n int) Option {
+	return func(e *yamlEater) {
+		e.parallelDecode = n
+	}
 }
 
 // NewYamlEater returns a YamlEater obj with given data source.
 // The read source should be one of: []byte content, io.Reader, io.ReadCloser or a string representing a file path.
-func NewYamlEater(read interface{}) (*yamlEater, error) {
+func NewYamlEater(read interface{}, opts ...Option) (*yamlEater, error) {
 	in, err := newReader(read)
 	if err != nil {
 		return nil, err
 	}
 
-	eater := &yamlEater{in, nil, nil,
-		nil, nil, make(chan []byte, ReadAhead), nil, nil}
+	eater := &yamlEater{in: in, readAhead: ReadAhead, ctx: context.Background()}
+
+	for _, opt := range opts {
+		opt(eater)
+	}
+
+	eater.ctx, eater.cancel = context.WithCancel(eater.ctx)
+	eater.readChan = make(chan rawDocument, eater.readAhead)
 
 	go eater.readYAML()
 
+	if eater.parallelDecode > 0 {
+		eater.decodedChan = make(chan decodedDoc, eater.readAhead)
+		go eater.decodePipeline()
+	}
+
 	return eater, nil
 }
 
+// NewYamlEaterWithContext is NewYamlEater with the eater's lifetime tied to ctx from the
+// start, equivalent to passing WithContext(ctx) as the first option.
+func NewYamlEaterWithContext(ctx context.Context, read interface{}, opts ...Option) (*yamlEater, error) {
+	return NewYamlEater(read, append([]Option{WithContext(ctx)}, opts...)...)
+}
+
+// Close cancels the eater's read-ahead goroutine and closes the underlying reader if it
+// is an io.Closer, unblocking a pending Read on readers that support that. Subsequent
+// Next/NextObj calls return ErrClosed immediately, even if documents were already
+// buffered ahead of the call. Safe to call more than once.
+func (e *yamlEater) Close() error {
+	e.closeOnce.Do(e.cancel)
+	return e.closeReader()
+}
+
+// closeReader closes e.in, if it is an io.Closer, exactly once: both finishRead (on a
+// normal EOF or read error) and Close race to do this, and closing twice is an error on
+// most io.Closer implementations.
+func (e *yamlEater) closeReader() error {
+	var err error
+	e.closeReaderOnce.Do(func() {
+		if closer, ok := e.in.(io.Closer); ok {
+			err = closer.Close()
+		}
+	})
+	return err
+}
+
 // Next returns the next full YAML documents in form of []byte, or an error.
 // The index for "Next" is in sync with "NextObj". It returns io.EOF error if reached the end.
 func (e *yamlEater) Next() ([]byte, error) {
+	if e.ctx.Err() != nil {
+		e.current = nil
+		return nil, ErrClosed
+	}
 	next, ok := <-e.readChan
 	if !ok {
 		e.current = nil
 		return nil, e.errRead
 	}
-	e.current = next
-	return next, nil
+	e.current = next.data
+	e.currentLoc = next.loc
+	return next.data, nil
+}
+
+// CurrentLocation returns where in the source the document returned by the most recent
+// Next/NextObj call came from. It is the zero DocumentLocation if called before either.
+func (e *yamlEater) CurrentLocation() DocumentLocation {
+	return e.currentLoc
 }
 
 // Current returns the current full YAML documents.
@@ -90,7 +254,7 @@ func (e *yamlEater) CurrentObj() (runtime.Object, *apischeme.GroupVersionKind, e
 			return nil, nil, fmt.Errorf(`method CurrentObj() called before NextObj()`)
 		} else if e.current != nil {
 			// didn't Decode the Object
-			obj, gvk, err := e.yamlDecoder.Decode(e.current, nil, nil)
+			obj, gvk, err := e.decode(e.current)
 			e.currentObj = &decodeResult{obj, gvk}
 			e.errDecode = err
 		} else if e.errRead != nil {
@@ -104,40 +268,362 @@ func (e *yamlEater) CurrentObj() (runtime.Object, *apischeme.GroupVersionKind, e
 // It will recognize all known typed resources registered in current API schema.
 // The index for "NextObj" is in sync with "Next". It returns io.EOF error if reached the end.
 func (e *yamlEater) NextObj() (runtime.Object, *apischeme.GroupVersionKind, error) {
+	if e.parallelDecode > 0 {
+		if e.ctx.Err() != nil {
+			e.current = nil
+			e.currentObj = nil
+			e.errDecode = ErrClosed
+			return nil, nil, ErrClosed
+		}
+		d, ok := <-e.decodedChan
+		if !ok {
+			e.current = nil
+			e.currentObj = nil
+			e.errDecode = e.errRead
+			return nil, nil, e.errRead
+		}
+		e.current = d.data
+		e.currentLoc = d.loc
+		e.currentObj = &decodeResult{d.obj, d.gvk}
+		e.errDecode = d.err
+		return d.obj, d.gvk, d.err
+	}
+
 	nextDoc, err := e.Next()
 	if err != nil {
 		e.currentObj = nil
 		e.errDecode = err
 		return nil, nil, err
 	}
-	obj, gvk, err := e.yamlDecoder.Decode(nextDoc, nil, nil)
+	obj, gvk, err := e.decode(nextDoc)
 	e.currentObj = &decodeResult{obj, gvk}
 	e.errDecode = err
 	return obj, gvk, err
 }
 
+// NextObjAt behaves like NextObj but also returns the document's source location, for
+// callers that want to report decode failures as "file.yaml:42: ...".
+func (e *yamlEater) NextObjAt() (runtime.Object, *apischeme.GroupVersionKind, DocumentLocation, error) {
+	obj, gvk, err := e.NextObj()
+	return obj, gvk, e.currentLoc, err
+}
+
+// resolveDecoder picks the decoder to use for this eater: an explicit WithDecoder wins,
+// then a codec factory built on a WithScheme scheme, falling back to client-go's
+// built-in scheme when neither option was given.
+func (e *yamlEater) resolveDecoder() runtime.Decoder {
+	if e.customDecoder != nil {
+		return e.customDecoder
+	}
+	if e.scheme != nil {
+		return serializer.NewCodecFactory(e.scheme).UniversalDeserializer()
+	}
+	return scheme.Codecs.UniversalDeserializer()
+}
+
+// decode decodes a raw document through e.yamlDecoder. If the GVK is not registered in
+// the current scheme and unstructured fallback is enabled, it falls back to decoding
+// into *unstructured.Unstructured (or *unstructured.UnstructuredList for a "*List" kind).
+func (e *yamlEater) decode(raw []byte) (runtime.Object, *apischeme.GroupVersionKind, error) {
+	obj, gvk, err := e.yamlDecoder.Decode(raw, nil, nil)
+	if err == nil || !e.unstructuredFallback || !runtime.IsNotRegisteredError(err) {
+		return obj, gvk, err
+	}
+
+	asJSON, jsonErr := k8syaml.ToJSON(raw)
+	if jsonErr != nil {
+		return obj, gvk, err
+	}
+
+	m := map[string]interface{}{}
+	if jsonErr := json.Unmarshal(asJSON, &m); jsonErr != nil {
+		return obj, gvk, err
+	}
+
+	u := &unstructured.Unstructured{Object: m}
+	unstructuredGvk := u.GroupVersionKind()
+
+	if strings.HasSuffix(unstructuredGvk.Kind, "List") {
+		list, listErr := toUnstructuredList(u)
+		if listErr != nil {
+			return obj, gvk, err
+		}
+		return list, &unstructuredGvk, nil
+	}
+
+	return u, &unstructuredGvk, nil
+}
+
+// decodePipeline fans raw documents off readChan out to e.parallelDecode worker
+// goroutines, then resequences their results back into original order onto decodedChan.
+// It runs until readChan is closed and every in-flight decode has been forwarded.
+func (e *yamlEater) decodePipeline() {
+	work := make(chan rawDocument)
+	results := make(chan decodedDoc)
+
+	var workers sync.WaitGroup
+	workers.Add(e.parallelDecode)
+	for i := 0; i < e.parallelDecode; i++ {
+		go func() {
+			defer workers.Done()
+			for raw := range work {
+				obj, gvk, err := e.decode(raw.data)
+				results <- decodedDoc{rawDocument: raw, obj: obj, gvk: gvk, err: err}
+			}
+		}()
+	}
+
+	// tokens bounds how many documents may be in flight between intake and resequencing at
+	// once (released only once a document is popped off pending, in order, to be forwarded
+	// to decodedChan), so a single slow document
+	// sitting at the head of pending can't let the rest of the stream pile up unbounded.
+	tokens := make(chan struct{}, e.parallelDecode+int(e.readAhead))
+	go func() {
+		for raw := range e.readChan {
+			select {
+			case tokens <- struct{}{}:
+			case <-e.ctx.Done():
+				close(work)
+				return
+			}
+			work <- raw
+		}
+		close(work)
+	}()
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	pending := &decodedHeap{}
+	next := uint64(0)
+	for r := range results {
+		heap.Push(pending, r)
+		for pending.Len() > 0 && (*pending)[0].seq == next {
+			d := heap.Pop(pending).(decodedDoc)
+			<-tokens
+			select {
+			case e.decodedChan <- d:
+				next++
+			case <-e.ctx.Done():
+				close(e.decodedChan)
+				// Workers may still be blocked sending their in-flight decode onto
+				// results; keep draining it until they (and the close(results)
+				// goroutine above) finish, or they'd leak forever.
+				for range results {
+				}
+				return
+			}
+		}
+	}
+	close(e.decodedChan)
+}
+
+// decodedHeap is a min-heap of decodedDoc ordered by seq, used by decodePipeline to hold
+// results that finished out of order until the one it's waiting on arrives.
+type decodedHeap []decodedDoc
+
+func (h decodedHeap) Len() int            { return len(h) }
+func (h decodedHeap) Less(i, j int) bool  { return h[i].seq < h[j].seq }
+func (h decodedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *decodedHeap) Push(x interface{}) { *h = append(*h, x.(decodedDoc)) }
+func (h *decodedHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// toUnstructuredList reinterprets an *unstructured.Unstructured decoded from a "*List"
+// kind document as an *unstructured.UnstructuredList, expanding its "items" field.
+func toUnstructuredList(u *unstructured.Unstructured) (*unstructured.UnstructuredList, error) {
+	items, found, err := unstructured.NestedSlice(u.Object, "items")
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{Object: u.Object}
+	if found {
+		list.Items = make([]unstructured.Unstructured, 0, len(items))
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			list.Items = append(list.Items, unstructured.Unstructured{Object: m})
+		}
+	}
+	return list, nil
+}
+
 // runs in separated goroutine
 func (e *yamlEater) readYAML() {
-	// init Reader and Decoder
-	e.yamlReader = k8syaml.NewYAMLReader(bufio.NewReader(e.in))
-	e.yamlDecoder = scheme.Codecs.UniversalDeserializer()
+	// init Decoder
+	e.yamlDecoder = e.resolveDecoder()
 
+	buffered := bufio.NewReader(e.in)
+	first, err := peekFirstNonSpace(buffered)
+	if err != nil {
+		e.finishRead(err)
+		return
+	}
+
+	switch first {
+	case '{':
+		// a single JSON object stream, eg: `kubectl get -o json` on one resource
+		e.readJSONObjects(buffered)
+	case '[':
+		// a JSON array of objects, eg: argo/helm template dumps or `kubectl get -o json` on a list
+		e.readJSONArray(buffered)
+	default:
+		e.yamlReader = k8syaml.NewYAMLReader(buffered)
+		e.readYAMLDocs()
+	}
+}
+
+// yamlDocSeparator is the "---" line k8syaml.YAMLReader strips between documents. It isn't
+// included in the []byte a Read() call returns, so readYAMLDocs has to account for it by
+// hand when it accumulates line/byte counts across documents.
+const yamlDocSeparator = "---\n"
+
+// readYAMLDocs streams one or more YAML documents separated by "---" off e.yamlReader,
+// deriving each document's line range and byte offset from the bytes each Read() call
+// actually returns, since bufio.Reader buffers ahead of what YAMLReader has consumed and
+// can't be counted reliably from below.
+func (e *yamlEater) readYAMLDocs() {
+	var lines int
+	var byteOffset int64
 	for {
+		startLine, startByte := lines+1, byteOffset
 		read, err := e.yamlReader.Read()
 		if err != nil {
 			// err could be io.EOF
-			e.errRead = err
-			// if in is an io.ReadCloser, eg: fileDescriptor. Close it on err or io.EOF
-			if readCloser, ok := e.in.(io.ReadCloser); ok {
-				_ = readCloser.Close()
-			}
-			close(e.readChan)
+			e.finishRead(err)
 			return
 		}
+		lines += bytes.Count(read, []byte{'\n'})
+		byteOffset += int64(len(read))
+		loc := DocumentLocation{StartLine: startLine, EndLine: lines, ByteOffset: startByte}
+		// account for the separator consumed ahead of the next document
+		lines++
+		byteOffset += int64(len(yamlDocSeparator))
+
 		// validate the YAML by converting it to JSON
 		jsonTest, err := k8syaml.ToJSON(read)
 		if !bytes.Equal(jsonTest, invalidJSON) && !bytes.Equal(jsonTest, emptyJSON) {
-			e.readChan <- read
+			if !e.send(rawDocument{data: read, loc: loc}) {
+				e.finishRead(ErrClosed)
+				return
+			}
+		}
+	}
+}
+
+// readJSONObjects streams a concatenated run of top-level JSON objects off buffered, eg.
+// JSON-lines or `{...}{...}`, one document per object.
+func (e *yamlEater) readJSONObjects(buffered *bufio.Reader) {
+	dec := json.NewDecoder(buffered)
+	for {
+		startByte := dec.InputOffset()
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				e.finishRead(io.EOF)
+				return
+			}
+			e.finishRead(err)
+			return
+		}
+		if sent := e.emitCanonicalJSON(raw, DocumentLocation{ByteOffset: startByte}); !sent {
+			e.finishRead(ErrClosed)
+			return
+		}
+	}
+}
+
+// readJSONArray streams the elements of a top-level JSON array off buffered, one per document.
+func (e *yamlEater) readJSONArray(buffered *bufio.Reader) {
+	dec := json.NewDecoder(buffered)
+	if _, err := dec.Token(); err != nil {
+		// the opening '['
+		e.finishRead(err)
+		return
+	}
+
+	for dec.More() {
+		startByte := dec.InputOffset()
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			e.finishRead(err)
+			return
+		}
+		if sent := e.emitCanonicalJSON(raw, DocumentLocation{ByteOffset: startByte}); !sent {
+			e.finishRead(ErrClosed)
+			return
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		// the closing ']'
+		e.finishRead(err)
+		return
+	}
+	e.finishRead(io.EOF)
+}
+
+// emitCanonicalJSON pushes raw onto readChan as canonicalized (whitespace-compacted) bytes
+// at the given location, skipping "null" and "{}" the same way readYAMLDocs does. It
+// returns false if the eater was closed before the document could be sent.
+func (e *yamlEater) emitCanonicalJSON(raw json.RawMessage, loc DocumentLocation) bool {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return true
+	}
+	canonical := compact.Bytes()
+	if bytes.Equal(canonical, invalidJSON) || bytes.Equal(canonical, emptyJSON) {
+		return true
+	}
+	return e.send(rawDocument{data: append([]byte(nil), canonical...), loc: loc})
+}
+
+// send pushes doc onto readChan, or gives up and returns false if the eater's context is
+// cancelled (via Close) before the channel has room.
+func (e *yamlEater) send(doc rawDocument) bool {
+	doc.seq = e.readSeq
+	e.readSeq++
+	select {
+	case e.readChan <- doc:
+		return true
+	case <-e.ctx.Done():
+		return false
+	}
+}
+
+// finishRead records the terminal error (usually io.EOF), closes the underlying reader
+// if possible, and closes readChan so Next/NextObj observe the end of the stream.
+func (e *yamlEater) finishRead(err error) {
+	e.errRead = err
+	// if in is an io.ReadCloser, eg: fileDescriptor, close it on err or io.EOF. closeReader
+	// is shared with Close so a later/concurrent Close doesn't close it a second time.
+	_ = e.closeReader()
+	close(e.readChan)
+}
+
+// peekFirstNonSpace returns the first non-whitespace byte from r without consuming
+// anything beyond it, skipping past any leading whitespace in the process.
+func peekFirstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return b[0], nil
+		}
+		if _, err := r.Discard(1); err != nil {
+			return 0, err
 		}
 	}
 }
@@ -150,7 +636,7 @@ func newReader(read interface{}) (io.Reader, error) {
 
 	switch v.Kind() {
 	case reflect.Slice:
-		elmV := v.Elem()
+		elmV := v.Type().Elem()
 		// expect byte slice
 		if elmV.Kind() != reflect.Uint8 {
 			return nil, fmt.Errorf(`unexpect %s slice, only []byte allowed`, v.Type().String())
@@ -0,0 +1,334 @@
+package yamleater
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func configMapYAML(name string) string {
+	return fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", name)
+}
+
+func configMapJSON(name string) string {
+	return fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":%q}}`, name)
+}
+
+func drainNames(t *testing.T, e *yamlEater, want int) []string {
+	t.Helper()
+	var names []string
+	for {
+		obj, gvk, err := e.NextObj()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextObj: %v", err)
+		}
+		if gvk.Kind != "ConfigMap" {
+			t.Fatalf("got kind %q, want ConfigMap", gvk.Kind)
+		}
+		accessor, err := objName(obj)
+		if err != nil {
+			t.Fatalf("objName: %v", err)
+		}
+		names = append(names, accessor)
+	}
+	if len(names) != want {
+		t.Fatalf("got %d objects, want %d", len(names), want)
+	}
+	return names
+}
+
+// objName pulls metadata.name off a decoded runtime.Object without depending on a concrete
+// type; it also accepts *unstructured.Unstructured, though the fixtures here are plain ConfigMaps.
+func objName(obj interface{}) (string, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetName(), nil
+	}
+	accessor, ok := obj.(interface{ GetName() string })
+	if !ok {
+		return "", fmt.Errorf("object %T has no GetName", obj)
+	}
+	return accessor.GetName(), nil
+}
+
+func TestReadYAMLDocuments(t *testing.T) {
+	src := configMapYAML("cm0") + "---\n" + configMapYAML("cm1") + "---\n" + configMapYAML("cm2")
+	e, err := NewYamlEater([]byte(src))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	defer e.Close()
+
+	names := drainNames(t, e, 3)
+	for i, want := range []string{"cm0", "cm1", "cm2"} {
+		if names[i] != want {
+			t.Errorf("doc %d: got name %q, want %q", i, names[i], want)
+		}
+	}
+}
+
+func TestDocumentLocationTracksLinesAndOffsets(t *testing.T) {
+	src := configMapYAML("cm0") + "---\n" + configMapYAML("cm1")
+	e, err := NewYamlEater([]byte(src))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	defer e.Close()
+
+	if _, _, err := e.NextObj(); err != nil {
+		t.Fatalf("NextObj (1st): %v", err)
+	}
+	firstLoc := e.CurrentLocation()
+	if firstLoc.StartLine != 1 {
+		t.Errorf("first doc StartLine = %d, want 1", firstLoc.StartLine)
+	}
+	if firstLoc.ByteOffset != 0 {
+		t.Errorf("first doc ByteOffset = %d, want 0", firstLoc.ByteOffset)
+	}
+
+	if _, _, err := e.NextObj(); err != nil {
+		t.Fatalf("NextObj (2nd): %v", err)
+	}
+	secondLoc := e.CurrentLocation()
+	if secondLoc.StartLine <= firstLoc.EndLine {
+		t.Errorf("second doc StartLine %d should be after first doc EndLine %d", secondLoc.StartLine, firstLoc.EndLine)
+	}
+	if secondLoc.ByteOffset <= firstLoc.ByteOffset {
+		t.Errorf("second doc ByteOffset %d should be after first doc ByteOffset %d", secondLoc.ByteOffset, firstLoc.ByteOffset)
+	}
+
+	if _, _, err := e.NextObj(); err != io.EOF {
+		t.Fatalf("NextObj (3rd): got err %v, want io.EOF", err)
+	}
+}
+
+func TestReadJSONArray(t *testing.T) {
+	src := "[" + configMapJSON("cm0") + "," + configMapJSON("cm1") + "," + configMapJSON("cm2") + "]"
+	e, err := NewYamlEater([]byte(src))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	defer e.Close()
+
+	names := drainNames(t, e, 3)
+	for i, want := range []string{"cm0", "cm1", "cm2"} {
+		if names[i] != want {
+			t.Errorf("doc %d: got name %q, want %q", i, names[i], want)
+		}
+	}
+}
+
+func TestReadJSONObjectStream(t *testing.T) {
+	src := configMapJSON("cm0") + configMapJSON("cm1") + configMapJSON("cm2")
+	e, err := NewYamlEater([]byte(src))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	defer e.Close()
+
+	names := drainNames(t, e, 3)
+	for i, want := range []string{"cm0", "cm1", "cm2"} {
+		if names[i] != want {
+			t.Errorf("doc %d: got name %q, want %q", i, names[i], want)
+		}
+	}
+}
+
+func TestUnstructuredFallback(t *testing.T) {
+	src := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w0\nspec:\n  foo: bar\n"
+	e, err := NewYamlEater([]byte(src), WithUnstructuredFallback())
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	defer e.Close()
+
+	obj, gvk, err := e.NextObj()
+	if err != nil {
+		t.Fatalf("NextObj: %v", err)
+	}
+	if gvk.Kind != "Widget" {
+		t.Fatalf("got kind %q, want Widget", gvk.Kind)
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		t.Fatalf("got %T, want *unstructured.Unstructured", obj)
+	}
+	if foo, _, _ := unstructured.NestedString(u.Object, "spec", "foo"); foo != "bar" {
+		t.Errorf("spec.foo = %q, want bar", foo)
+	}
+}
+
+func TestCloseReturnsErrClosed(t *testing.T) {
+	var src string
+	for i := 0; i < 100; i++ {
+		src += configMapYAML(fmt.Sprintf("cm%d", i)) + "---\n"
+	}
+
+	// Use the default read-ahead so several documents are already buffered in readChan by
+	// the time Close is called, to prove Close short-circuits Next instead of only taking
+	// effect once the buffer drains.
+	e, err := NewYamlEater([]byte(src))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	for len(e.readChan) < cap(e.readChan) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := e.Next(); err != ErrClosed {
+			t.Fatalf("Next after Close: got %v, want ErrClosed", err)
+		}
+	}
+}
+
+// countingReadCloser wraps a bytes.Reader, counting Close calls and failing on a second
+// one, the way most real io.Closer implementations (eg. os.File) do.
+type countingReadCloser struct {
+	*bytes.Reader
+	closes int
+}
+
+func (c *countingReadCloser) Close() error {
+	c.closes++
+	if c.closes > 1 {
+		return fmt.Errorf("closed %d times, want at most 1", c.closes)
+	}
+	return nil
+}
+
+func TestCloseAfterEOFDoesNotDoubleCloseReader(t *testing.T) {
+	rc := &countingReadCloser{Reader: bytes.NewReader([]byte(configMapYAML("cm0")))}
+	e, err := NewYamlEater(rc)
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+
+	// Drain to EOF so finishRead closes rc on its own.
+	for {
+		if _, _, err := e.NextObj(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("NextObj: %v", err)
+		}
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close after EOF: %v", err)
+	}
+	if rc.closes != 1 {
+		t.Fatalf("reader closed %d times, want 1", rc.closes)
+	}
+}
+
+// benchSource builds n ConfigMap documents, each padded with enough data keys to make
+// decoding CPU-bound rather than I/O-bound, so BenchmarkParallelDecode reflects decode
+// throughput rather than how fast bytes.Reader can hand back bytes.
+func benchSource(n int) []byte {
+	var src string
+	for i := 0; i < n; i++ {
+		src += fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm%d\ndata:\n", i)
+		for k := 0; k < 50; k++ {
+			src += fmt.Sprintf("  key%d: %q\n", k, "some moderately sized value to decode")
+		}
+		src += "---\n"
+	}
+	return []byte(src)
+}
+
+// BenchmarkParallelDecode compares decode throughput across parallelism levels; it should
+// show near-linear speedup up to the number of available CPUs.
+func BenchmarkParallelDecode(b *testing.B) {
+	const docCount = 500
+	src := benchSource(docCount)
+
+	for _, n := range []int{1, 2, 4, 8} {
+		n := n
+		b.Run(fmt.Sprintf("workers=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				e, err := NewYamlEater(src, WithParallelDecode(n))
+				if err != nil {
+					b.Fatalf("NewYamlEater: %v", err)
+				}
+				for {
+					if _, _, err := e.NextObj(); err == io.EOF {
+						break
+					} else if err != nil {
+						b.Fatalf("NextObj: %v", err)
+					}
+				}
+				e.Close()
+			}
+		})
+	}
+}
+
+func TestParallelDecodePreservesOrder(t *testing.T) {
+	const docCount = 50
+	var src string
+	want := make([]string, docCount)
+	for i := 0; i < docCount; i++ {
+		name := fmt.Sprintf("cm%02d", i)
+		want[i] = name
+		src += configMapYAML(name) + "---\n"
+	}
+
+	e, err := NewYamlEater([]byte(src), WithParallelDecode(4))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	defer e.Close()
+
+	names := drainNames(t, e, docCount)
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("doc %d: got name %q, want %q", i, names[i], want[i])
+		}
+	}
+}
+
+// TestParallelDecodeCloseDoesNotLeakGoroutines exercises the case where a consumer stops
+// draining NextObj mid-stream and calls Close: the decode workers and the decodePipeline
+// goroutines must all exit rather than leak forever blocked on the resequencer's channels.
+func TestParallelDecodeCloseDoesNotLeakGoroutines(t *testing.T) {
+	const docCount = 200
+	var src string
+	for i := 0; i < docCount; i++ {
+		src += configMapYAML(fmt.Sprintf("cm%d", i)) + "---\n"
+	}
+
+	before := runtime.NumGoroutine()
+
+	e, err := NewYamlEater([]byte(src), WithParallelDecode(4))
+	if err != nil {
+		t.Fatalf("NewYamlEater: %v", err)
+	}
+	if _, _, err := e.NextObj(); err != nil {
+		t.Fatalf("NextObj: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if runtime.NumGoroutine() <= before+2 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked after Close: before=%d now=%d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}